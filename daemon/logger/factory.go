@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Creator builds a Logger instance for a container, given that container's
+// Context.
+type Creator func(Context) (Logger, error)
+
+// LogOptValidator validates a driver's log opts ahead of container create,
+// so a typo surfaces immediately rather than when the driver is started.
+type LogOptValidator func(cfg map[string]string) error
+
+var (
+	mu            sync.Mutex
+	drivers       = make(map[string]Creator)
+	optValidators = make(map[string]LogOptValidator)
+)
+
+// RegisterLogDriver registers a Creator for a logging driver under name, so
+// that containers can select it via --log-driver.
+func RegisterLogDriver(name string, c Creator) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := drivers[name]; exists {
+		return fmt.Errorf("logger: log driver %q already registered", name)
+	}
+	drivers[name] = c
+	return nil
+}
+
+// RegisterLogOptValidator registers the log-opt validator for a logging
+// driver under name.
+func RegisterLogOptValidator(name string, l LogOptValidator) error {
+	mu.Lock()
+	defer mu.Unlock()
+	optValidators[name] = l
+	return nil
+}
+
+// GetLogDriver looks up the Creator registered for name.
+func GetLogDriver(name string) (Creator, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	c, exists := drivers[name]
+	if !exists {
+		return nil, fmt.Errorf("logger: no log driver named %q is registered", name)
+	}
+	return c, nil
+}
+
+// ValidateLogOpts runs the log-opt validator registered for name, if any.
+func ValidateLogOpts(name string, cfg map[string]string) error {
+	mu.Lock()
+	l, exists := optValidators[name]
+	mu.Unlock()
+	if !exists {
+		return nil
+	}
+	return l(cfg)
+}
@@ -5,6 +5,7 @@
 package journald
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -15,15 +16,27 @@ import (
 	"github.com/coreos/go-systemd/journal"
 	"github.com/docker/docker/daemon/logger"
 	"github.com/docker/docker/daemon/logger/loggerutils"
+	"github.com/docker/docker/daemon/logger/ratelimit"
 )
 
 const name = "journald"
 
 type journald struct {
-	vars      map[string]string // additional variables and values to send to the journal along with the log message
-	eVars     map[string]string // vars, plus an extra one saying DOCKER_EVENT=true
-	readers   readerList
-	rateLimit *rateLimit
+	vars         map[string]string // additional variables and values to send to the journal along with the log message
+	eVars        map[string]string // vars, plus an extra one saying DOCKER_EVENT=true
+	diedVars     map[string]string // eVars, plus an extra one marking this as the end-of-logs sentinel
+	fields       []string          // keys to hoist out of JSON-structured container lines, see journald-fields
+	readers      readerList
+	rateLimiters streamRateLimiters
+}
+
+// streamRateLimiters holds the per-stream ratelimit.Limiter used to throttle
+// messages from the container before they're sent to journald. When
+// per-stream limiting isn't requested, stdout and stderr point at the same
+// Limiter, so they continue sharing a single budget as before.
+type streamRateLimiters struct {
+	stdout ratelimit.Limiter
+	stderr ratelimit.Limiter
 }
 
 type readerList struct {
@@ -40,10 +53,15 @@ func init() {
 	}
 }
 
-// Returns a rateLimit for the container if appropriate labels are set. Returns
-// nil if labels are not set or cannot be parsed. Logs errors if labels cannot
-// be parsed.
-func newRateLimit(labels map[string]string) *rateLimit {
+// newLegacyRateLimiter returns a window Limiter for the container if
+// appropriate labels are set. Returns nil if labels are not set or cannot be
+// parsed. Logs errors if labels cannot be parsed.
+//
+// This is the legacy, galaxy-specific way of configuring rate limiting, kept
+// around as a compatibility shim for containers that still use it. New
+// configuration should use the log-rate-* log opts instead, see
+// newRateLimiter.
+func newLegacyRateLimiter(labels map[string]string) ratelimit.Limiter {
 	burstLabel, burstExists := labels["com.meteor.galaxy.log-burst"]
 	intervalLabel, intervalExists := labels["com.meteor.galaxy.log-interval"]
 
@@ -70,7 +88,60 @@ func newRateLimit(labels map[string]string) *rateLimit {
 		return nil
 	}
 
-	return &rateLimit{Burst: burst, Interval: interval}
+	return ratelimit.NewWindow(burst, interval)
+}
+
+// newRateLimiter builds the Limiter described by the log-rate-* log opts, if
+// any are set, falling back to the legacy com.meteor.galaxy.log-* labels
+// otherwise. Returns nil if no rate limiting is configured.
+func newRateLimiter(cfg map[string]string, labels map[string]string) ratelimit.Limiter {
+	burstOpt, burstExists := cfg["log-rate-burst"]
+	intervalOpt, intervalExists := cfg["log-rate-interval"]
+	if !burstExists && !intervalExists {
+		return newLegacyRateLimiter(labels)
+	}
+
+	burst, err := strconv.Atoi(burstOpt)
+	if err != nil {
+		logrus.Errorf("Couldn't parse log-rate-burst '%s': %v", burstOpt, err)
+		return nil
+	}
+	if burst <= 0 {
+		logrus.Errorf("log-rate-burst '%s' must be positive", burstOpt)
+		return nil
+	}
+	interval, err := time.ParseDuration(intervalOpt)
+	if err != nil {
+		logrus.Errorf("Couldn't parse log-rate-interval '%s': %v", intervalOpt, err)
+		return nil
+	}
+	if interval <= 0 {
+		logrus.Errorf("log-rate-interval '%s' must be positive", intervalOpt)
+		return nil
+	}
+
+	switch policy := cfg["log-rate-policy"]; policy {
+	case "", "window":
+		return ratelimit.NewWindow(burst, interval)
+	case "token":
+		return ratelimit.NewTokenBucket(float64(burst)/interval.Seconds(), float64(burst))
+	default:
+		logrus.Errorf("Unknown log-rate-policy '%s'", policy)
+		return nil
+	}
+}
+
+// newStreamRateLimiters builds the stdout/stderr Limiter pair for a
+// container. When log-rate-per-stream is set, stdout and stderr each get
+// their own independent Limiter instance so that a noisy stderr can't starve
+// stdout; otherwise they share a single Limiter, preserving the historical
+// behavior of rate limiting the two streams together.
+func newStreamRateLimiters(cfg map[string]string, labels map[string]string) streamRateLimiters {
+	stdout := newRateLimiter(cfg, labels)
+	if perStream, _ := strconv.ParseBool(cfg["log-rate-per-stream"]); !perStream {
+		return streamRateLimiters{stdout: stdout, stderr: stdout}
+	}
+	return streamRateLimiters{stdout: stdout, stderr: newRateLimiter(cfg, labels)}
 }
 
 // New creates a journald logger using the configuration passed in on
@@ -108,26 +179,94 @@ func New(ctx logger.Context) (logger.Logger, error) {
 		eVars[k] = v
 	}
 
+	diedVars := map[string]string{"DOCKER_EVENT_TYPE": "died"}
+	for k, v := range eVars {
+		diedVars[k] = v
+	}
+
 	return &journald{
-		vars:      vars,
-		eVars:     eVars,
-		readers:   readerList{readers: make(map[*logger.LogWatcher]*logger.LogWatcher)},
-		rateLimit: newRateLimit(ctx.ContainerLabels),
+		vars:         vars,
+		eVars:        eVars,
+		diedVars:     diedVars,
+		fields:       parseFieldList(ctx.Config["journald-fields"]),
+		readers:      readerList{readers: make(map[*logger.LogWatcher]*logger.LogWatcher)},
+		rateLimiters: newStreamRateLimiters(ctx.Config, ctx.ContainerLabels),
 	}, nil
 }
 
-// We don't actually accept any options, but we have to supply a callback for
-// the factory to pass the (probably empty) configuration map to.
+// parseFieldList splits the comma-separated journald-fields log opt into the
+// list of JSON keys to hoist out of structured container lines.
+func parseFieldList(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	var fields []string
+	for _, key := range strings.Split(raw, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			fields = append(fields, key)
+		}
+	}
+	return fields
+}
+
 func validateLogOpt(cfg map[string]string) error {
 	for key := range cfg {
 		switch key {
 		case "labels":
 		case "env":
 		case "tag":
+		case "log-rate-burst":
+		case "log-rate-interval":
+		case "log-rate-per-stream":
+			switch cfg[key] {
+			case "true", "false":
+			default:
+				return fmt.Errorf("invalid log-rate-per-stream '%s' for journald log driver, must be 'true' or 'false'", cfg[key])
+			}
+		case "log-rate-policy":
+			switch cfg[key] {
+			case "window", "token":
+			default:
+				return fmt.Errorf("invalid log-rate-policy '%s' for journald log driver, must be 'window' or 'token'", cfg[key])
+			}
+		case "journald-fields":
 		default:
 			return fmt.Errorf("unknown log opt '%s' for journald log driver", key)
 		}
 	}
+
+	_, burstExists := cfg["log-rate-burst"]
+	if burst, ok := cfg["log-rate-burst"]; ok {
+		parsed, err := strconv.Atoi(burst)
+		if err != nil {
+			return fmt.Errorf("invalid log-rate-burst '%s' for journald log driver: %v", burst, err)
+		}
+		if parsed <= 0 {
+			return fmt.Errorf("invalid log-rate-burst '%s' for journald log driver: must be positive", burst)
+		}
+	}
+
+	_, intervalExists := cfg["log-rate-interval"]
+	if interval, ok := cfg["log-rate-interval"]; ok {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("invalid log-rate-interval '%s' for journald log driver: %v", interval, err)
+		}
+		if parsed <= 0 {
+			return fmt.Errorf("invalid log-rate-interval '%s' for journald log driver: must be positive", interval)
+		}
+	}
+
+	// log-rate-burst and log-rate-interval must be set together, and
+	// log-rate-policy only makes sense alongside them: without a burst and
+	// interval there's nothing for it to apply to.
+	if burstExists != intervalExists {
+		return fmt.Errorf("log-rate-burst and log-rate-interval must be set together for journald log driver")
+	}
+	if _, policyExists := cfg["log-rate-policy"]; policyExists && !burstExists {
+		return fmt.Errorf("log-rate-policy requires log-rate-burst and log-rate-interval to be set for journald log driver")
+	}
+
 	return nil
 }
 
@@ -143,10 +282,14 @@ func (s *journald) Log(msg *logger.Message) error {
 		return journal.Send(string(msg.Line), journal.PriWarning, s.eVars)
 	}
 
-	// If it's actually from the container, apply rate limiting. Note that we
-	// don't rate limit stdout and stderr separately from each other.
-	if s.rateLimit != nil {
-		allowed, suppressed := s.rateLimit.Check()
+	// If it's actually from the container, apply rate limiting. Unless
+	// log-rate-per-stream is set, stdout and stderr share a single budget.
+	limiter := s.rateLimiters.stdout
+	if msg.Source == "stderr" {
+		limiter = s.rateLimiters.stderr
+	}
+	if limiter != nil {
+		allowed, suppressed := limiter.Check()
 		if !allowed {
 			return nil
 		}
@@ -157,10 +300,119 @@ func (s *journald) Log(msg *logger.Message) error {
 		}
 	}
 
+	priority := journal.PriInfo
 	if msg.Source == "stderr" {
-		return journal.Send(string(msg.Line), journal.PriErr, s.vars)
+		priority = journal.PriErr
+	}
+	// PRIORITY can be overridden below by a hoisted level/severity field, so
+	// it can no longer be trusted to tell stdout from stderr on the way
+	// back out. CONTAINER_STREAM is: the reader keys off of it instead.
+	vars := s.streamVars(msg.Source)
+	if len(s.fields) > 0 {
+		vars, priority = s.hoistFields(msg.Line, vars, priority)
+	}
+	return journal.Send(string(msg.Line), priority, vars)
+}
+
+// streamVars returns a copy of s.vars tagged with which stream the message
+// came from.
+func (s *journald) streamVars(source string) map[string]string {
+	vars := make(map[string]string, len(s.vars)+1)
+	for k, v := range s.vars {
+		vars[k] = v
+	}
+	vars[fieldContainerStream] = source
+	return vars
+}
+
+// hoistFields attempts to parse line as JSON and, for each key configured
+// via journald-fields that's present with a scalar value, copies it into
+// vars under a normalized CONTAINER_FIELD_ name. If line's "level" or
+// "severity" (whichever is configured) names a recognized syslog level, it
+// overrides defaultPriority. Falls back to (vars, defaultPriority) unchanged
+// whenever line isn't valid JSON.
+func (s *journald) hoistFields(line []byte, vars map[string]string, defaultPriority journal.Priority) (map[string]string, journal.Priority) {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(line, &parsed); err != nil {
+		return vars, defaultPriority
+	}
+
+	priority := defaultPriority
+	for _, key := range s.fields {
+		raw, exists := parsed[key]
+		if !exists {
+			continue
+		}
+		value, ok := scalarString(raw)
+		if !ok {
+			continue
+		}
+		if name, ok := normalizeFieldName(key); ok {
+			vars[name] = value
+		}
+		if key == "level" || key == "severity" {
+			if p, ok := levelToPriority(value); ok {
+				priority = p
+			}
+		}
+	}
+	return vars, priority
+}
+
+// normalizeFieldName turns a journald-fields key into the journal field name
+// it's hoisted under, e.g. "trace-id" -> "CONTAINER_FIELD_TRACE_ID". journald
+// field names may only contain [A-Z0-9_], so any other character (".", a
+// space, ...) is replaced with "_". The second return value is false for an
+// empty key, since journal.Send would reject the whole message over a
+// single malformed field name rather than just dropping that field.
+func normalizeFieldName(key string) (string, bool) {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(key) {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "", false
+	}
+	return "CONTAINER_FIELD_" + b.String(), true
+}
+
+// scalarString renders a decoded JSON value as a string, for the field types
+// that make sense as journal field values. Nested objects/arrays don't.
+func scalarString(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(t), true
+	default:
+		return "", false
+	}
+}
+
+// levelToPriority maps a recognized level/severity value to the matching
+// journal.Priority.
+func levelToPriority(level string) (journal.Priority, bool) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return journal.PriDebug, true
+	case "info", "information":
+		return journal.PriInfo, true
+	case "warn", "warning":
+		return journal.PriWarning, true
+	case "err", "error":
+		return journal.PriErr, true
+	case "crit", "critical", "fatal":
+		return journal.PriCrit, true
+	default:
+		return 0, false
 	}
-	return journal.Send(string(msg.Line), journal.PriInfo, s.vars)
 }
 
 // Send a DOCKER_EVENT message describing the suppression.
@@ -169,6 +421,16 @@ func (s *journald) sendSuppressedMessage(suppressed int) error {
 	return journal.Send(suppressedMessage, journal.PriWarning, s.eVars)
 }
 
+// Died sends the sentinel DOCKER_EVENT_TYPE=died marker through the same
+// journal.Send path used for every other message from this container. The
+// daemon calls this once it has finished copying the container's stdio into
+// the log driver, so that reading this entry back out of the journal is a
+// deterministic "all logs are in the journal before this point" barrier for
+// followers of `docker logs -f`.
+func (s *journald) Died() error {
+	return journal.Send("", journal.PriWarning, s.diedVars)
+}
+
 func (s *journald) Name() string {
 	return name
 }
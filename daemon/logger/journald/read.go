@@ -0,0 +1,306 @@
+// +build linux
+
+package journald
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/coreos/go-systemd/journal"
+	"github.com/coreos/go-systemd/sdjournal"
+	"github.com/docker/docker/daemon/logger"
+)
+
+// Make sure the journald driver implements the logger.LogReader interface,
+// so that `docker logs` can pull history (and, with Follow, tail) straight
+// out of the journal.
+var _ logger.LogReader = &journald{}
+
+const fieldContainerIDFull = "CONTAINER_ID_FULL"
+
+// fieldContainerStream names the journal field that records which stream
+// (stdout/stderr) a message came from. It's independent of PRIORITY, which
+// journald-fields can remap from the line's own "level"/"severity".
+const fieldContainerStream = "CONTAINER_STREAM"
+
+// waitInterval is how long we block in sd_journal_wait between polls for the
+// watcher being closed while following.
+const waitInterval = 250 * time.Millisecond
+
+// ReadLogs implements the logger.LogReader interface for the journald driver.
+// It starts a goroutine that reads (and optionally follows) the container's
+// journal entries, translating them into logger.Message and pushing them
+// onto the returned LogWatcher's Msg channel.
+func (s *journald) ReadLogs(config logger.ReadConfig) *logger.LogWatcher {
+	watcher := logger.NewLogWatcher()
+	s.readers.add(watcher)
+	go s.readLogs(watcher, config)
+	return watcher
+}
+
+func (s *journald) readLogs(watcher *logger.LogWatcher, config logger.ReadConfig) {
+	defer s.readers.delete(watcher)
+	defer close(watcher.Msg)
+
+	j, err := sdjournal.NewJournal()
+	if err != nil {
+		watcher.Err <- err
+		return
+	}
+	defer j.Close()
+
+	if err := j.AddMatch(fieldContainerIDFull + "=" + s.vars[fieldContainerIDFull]); err != nil {
+		watcher.Err <- err
+		return
+	}
+
+	if err := seekJournal(j, config); err != nil {
+		watcher.Err <- err
+		return
+	}
+
+	// sawDied tracks whether the most recently consumed entry was the "died"
+	// sentinel, so that reaching the true end of the journal right after one
+	// (below) is recognized as deterministic EOF. A died marker followed by
+	// more entries (the container was restarted under the same ID) just gets
+	// cleared again once those entries are read, rather than stopping the
+	// read partway through the container's history.
+	sawDied := false
+
+	for {
+		n, err := j.Next()
+		if err != nil {
+			watcher.Err <- err
+			return
+		}
+		if n == 0 {
+			if !config.Follow {
+				return
+			}
+			if sawDied {
+				// Nothing has been written since the died sentinel: the
+				// container isn't running right now, and a restart would
+				// write fresh entries this read would otherwise have to
+				// wait forever for. Stop instead; a later ReadLogs call
+				// picks up from here if the container comes back.
+				return
+			}
+			if untilReached(config) {
+				// config.Until is in the past (or just arrived): the
+				// container can't have written anything after it yet, so
+				// there's nothing left to wait for.
+				return
+			}
+			select {
+			case <-watcher.WatchClose():
+				return
+			default:
+				j.Wait(waitInterval)
+				continue
+			}
+		}
+
+		msg, died, ok := s.toMessage(j)
+		if died {
+			// Bookkeeping only, never delivered: see the sawDied check
+			// above for why it isn't treated as an unconditional stop.
+			sawDied = true
+			continue
+		}
+		if !ok {
+			continue
+		}
+		sawDied = false
+
+		if !config.Until.IsZero() && msg.Timestamp.After(config.Until) {
+			// A real entry can't be timestamped later than "now", so seeing
+			// one past Until (follow or not) means we've reached the bound.
+			return
+		}
+
+		select {
+		case watcher.Msg <- msg:
+		case <-watcher.WatchClose():
+			return
+		}
+	}
+}
+
+// seekJournal positions j at the first entry that should be returned,
+// honoring Tail (seek to the end and step back) or Since (seek to a
+// realtime timestamp), defaulting to the beginning of the journal. Until is
+// not used to position the start of the read: the journal only supports
+// seeking to a point, not bounding a range, so the upper bound is instead
+// enforced entry-by-entry as we read (see untilReached and the Timestamp
+// check in readLogs).
+func seekJournal(j *sdjournal.Journal, config logger.ReadConfig) error {
+	switch {
+	case config.Tail > 0:
+		return seekTail(j, config.Tail)
+	case !config.Since.IsZero():
+		if err := j.SeekRealtimeUsec(uint64(config.Since.UnixNano() / int64(time.Microsecond))); err != nil {
+			return err
+		}
+	default:
+		if err := j.SeekHead(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tailSeeker is the subset of *sdjournal.Journal that seekTail needs. It
+// exists so seekTail can be exercised against a fake journal in tests.
+type tailSeeker interface {
+	SeekTail() error
+	SeekHead() error
+	Previous() (uint64, error)
+	GetEntry() (*sdjournal.JournalEntry, error)
+}
+
+// seekTail positions j so that the next tail calls to Next() yield exactly
+// the last `tail` entries that `docker logs` would actually display. It
+// walks backwards one entry at a time rather than using PreviousSkip,
+// because the CONTAINER_ID_FULL match also matches this container's
+// DOCKER_EVENT bookkeeping entries (start/stop/died/dropped notices): a
+// plain skip-by-count would count those against `tail`, under-filling the
+// window with real container lines.
+func seekTail(j tailSeeker, tail int) error {
+	if err := j.SeekTail(); err != nil {
+		return err
+	}
+
+	counted := 0
+	for counted < tail {
+		n, err := j.Previous()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// Ran out of journal before finding `tail` real entries: the
+			// cursor is already sitting on the oldest entry (Previous
+			// leaves the position unchanged when there's nothing earlier),
+			// so seek back to the head explicitly rather than returning
+			// with the cursor ON the first entry, which would make the
+			// read loop's first Next() skip straight past it.
+			return j.SeekHead()
+		}
+		if !isEventEntry(j) {
+			counted++
+		}
+	}
+
+	// Step back one more so the read loop's first Next() lands on the first
+	// of the tail real entries just counted. If there's nothing earlier
+	// (exactly `tail` real entries exist with nothing before them), the
+	// same off-by-one applies: seek to the head instead of leaving the
+	// cursor on that first entry.
+	n, err := j.Previous()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return j.SeekHead()
+	}
+	return nil
+}
+
+// isEventEntry reports whether the entry j is currently positioned at is a
+// DOCKER_EVENT bookkeeping entry rather than a real container log line.
+func isEventEntry(j tailSeeker) bool {
+	entry, err := j.GetEntry()
+	if err != nil {
+		return false
+	}
+	return entry.Fields["DOCKER_EVENT"] == "true"
+}
+
+// untilReached reports whether config.Until is set and has already arrived,
+// meaning a follower has nothing left to wait for: the container can't
+// produce a journal entry timestamped in the past.
+func untilReached(config logger.ReadConfig) bool {
+	return !config.Until.IsZero() && !time.Now().Before(config.Until)
+}
+
+// toMessage converts the journal entry j is currently positioned at into a
+// logger.Message. The second return value reports whether the entry was the
+// "died" sentinel sent by journald.Died: it's never delivered, but the
+// caller uses it to recognize the deterministic EOF it provides for a
+// follower (see the sawDied handling in readLogs). The third reports
+// whether msg is valid and should be delivered: DOCKER_EVENT entries are
+// internal bookkeeping (start, stop, suppressed-message notices, died, ...)
+// and are filtered out of normal `docker logs` output.
+func (s *journald) toMessage(j *sdjournal.Journal) (msg *logger.Message, died bool, ok bool) {
+	entry, err := j.GetEntry()
+	if err != nil {
+		logrus.Errorf("journald: error reading journal entry: %v", err)
+		return nil, false, false
+	}
+
+	if entry.Fields["DOCKER_EVENT"] == "true" {
+		if entry.Fields["DOCKER_EVENT_TYPE"] == "died" {
+			return nil, true, false
+		}
+		if suppressed, isDropped := droppedLines(entry.Fields["MESSAGE"]); isDropped {
+			logrus.Debugf("journald: %d container log lines were dropped by rate limiting", suppressed)
+		}
+		return nil, false, false
+	}
+
+	timestamp := time.Unix(0, int64(entry.RealtimeTimestamp)*int64(time.Microsecond))
+	source := entry.Fields[fieldContainerStream]
+	if source != "stdout" && source != "stderr" {
+		// CONTAINER_STREAM predates journald-fields priority remapping;
+		// fall back to PRIORITY for entries written before it existed.
+		source = "stdout"
+		if entry.Fields["PRIORITY"] == strconv.Itoa(int(journal.PriErr)) {
+			source = "stderr"
+		}
+	}
+
+	return &logger.Message{
+		Line:      []byte(entry.Fields["MESSAGE"]),
+		Source:    source,
+		Timestamp: timestamp,
+	}, false, true
+}
+
+// droppedLines reports whether a DOCKER_EVENT message is the "dropped"
+// notice sent by sendSuppressedMessage, and if so how many lines it covers.
+func droppedLines(message string) (int, bool) {
+	const prefix = `{"type":"dropped","lines":`
+	if !strings.HasPrefix(message, prefix) {
+		return 0, false
+	}
+	var suppressed int
+	if _, err := fmt.Sscanf(message, `{"type":"dropped","lines":%d}`, &suppressed); err != nil {
+		return 0, false
+	}
+	return suppressed, true
+}
+
+func (rl *readerList) add(w *logger.LogWatcher) {
+	rl.mu.Lock()
+	rl.readers[w] = w
+	rl.mu.Unlock()
+}
+
+func (rl *readerList) delete(w *logger.LogWatcher) {
+	rl.mu.Lock()
+	delete(rl.readers, w)
+	rl.mu.Unlock()
+}
+
+// Close closes the driver and wakes any readers that are blocked following
+// the journal, so `docker logs -f` unblocks once the container is removed.
+func (s *journald) Close() error {
+	s.readers.mu.Lock()
+	for _, w := range s.readers.readers {
+		w.Close()
+	}
+	s.readers.mu.Unlock()
+	return nil
+}
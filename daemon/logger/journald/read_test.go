@@ -0,0 +1,115 @@
+// +build linux
+
+package journald
+
+import (
+	"testing"
+
+	"github.com/coreos/go-systemd/sdjournal"
+)
+
+// fakeTailSeeker is a minimal in-memory stand-in for *sdjournal.Journal,
+// just enough to exercise seekTail without a real systemd journal.
+type fakeTailSeeker struct {
+	entries []sdjournal.JournalEntry // oldest first
+	pos     int                      // index of the entry under the cursor; len(entries) means positioned past the last entry, as after SeekTail
+}
+
+func (f *fakeTailSeeker) SeekHead() error {
+	f.pos = -1
+	return nil
+}
+
+func (f *fakeTailSeeker) SeekTail() error {
+	f.pos = len(f.entries)
+	return nil
+}
+
+func (f *fakeTailSeeker) Previous() (uint64, error) {
+	if f.pos <= 0 {
+		return 0, nil
+	}
+	f.pos--
+	return 1, nil
+}
+
+func (f *fakeTailSeeker) Next() (uint64, error) {
+	if f.pos >= len(f.entries)-1 {
+		f.pos = len(f.entries)
+		return 0, nil
+	}
+	f.pos++
+	return 1, nil
+}
+
+func (f *fakeTailSeeker) GetEntry() (*sdjournal.JournalEntry, error) {
+	return &f.entries[f.pos], nil
+}
+
+func entry(message string, event bool) sdjournal.JournalEntry {
+	fields := map[string]string{"MESSAGE": message}
+	if event {
+		fields["DOCKER_EVENT"] = "true"
+	}
+	return sdjournal.JournalEntry{Fields: fields}
+}
+
+func TestSeekTail(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		entries   []sdjournal.JournalEntry
+		tail      int
+		wantFirst string
+	}{
+		{
+			name: "tail smaller than available, skips event entries",
+			entries: []sdjournal.JournalEntry{
+				entry("start", true),
+				entry("one", false),
+				entry("two", false),
+				entry("three", false),
+			},
+			tail:      2,
+			wantFirst: "two",
+		},
+		{
+			name: "tail exceeds available, lands on the oldest real entry",
+			entries: []sdjournal.JournalEntry{
+				entry("one", false),
+				entry("two", false),
+			},
+			tail:      10,
+			wantFirst: "one",
+		},
+		{
+			name: "tail exactly matches available",
+			entries: []sdjournal.JournalEntry{
+				entry("one", false),
+				entry("two", false),
+			},
+			tail:      2,
+			wantFirst: "one",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeTailSeeker{entries: tt.entries}
+			if err := seekTail(f, tt.tail); err != nil {
+				t.Fatalf("seekTail: %v", err)
+			}
+			n, err := f.Next()
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			if n == 0 {
+				t.Fatalf("Next() = 0, want the entry %q", tt.wantFirst)
+			}
+			got, err := f.GetEntry()
+			if err != nil {
+				t.Fatalf("GetEntry: %v", err)
+			}
+			if got.Fields["MESSAGE"] != tt.wantFirst {
+				t.Errorf("first entry MESSAGE = %q, want %q", got.Fields["MESSAGE"], tt.wantFirst)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Package logger defines interfaces that logging drivers implement to
+// log messages.
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// Message is a data structure that represents piece of output produced by
+// some container. The Line member is a slice of an array whose contents can
+// be changed after a log driver's Log() method returns.
+type Message struct {
+	Line      []byte
+	Source    string
+	Timestamp time.Time
+}
+
+// Logger is the interface for docker logging drivers.
+type Logger interface {
+	Log(*Message) error
+	Name() string
+	Close() error
+}
+
+// ReadConfig is used to configure reads for a Logger's LogReader.
+type ReadConfig struct {
+	Since  time.Time
+	Until  time.Time
+	Tail   int
+	Follow bool
+}
+
+// LogReader is the interface for reading log messages for loggers that
+// support reading.
+type LogReader interface {
+	// ReadLogs reads logs produced by the container and returns a
+	// LogWatcher that streams the results.
+	ReadLogs(ReadConfig) *LogWatcher
+}
+
+// LogWatcher is used when consuming logs read from the LogReader interface.
+type LogWatcher struct {
+	// Msg is used to send log messages to a reader.
+	Msg chan *Message
+	// Err is used to send error messages that occur while reading logs.
+	Err chan error
+
+	closeNotifier chan struct{}
+	closeOnce     sync.Once
+}
+
+// NewLogWatcher returns a new LogWatcher.
+func NewLogWatcher() *LogWatcher {
+	return &LogWatcher{
+		Msg:           make(chan *Message, logWatcherBufferSize),
+		Err:           make(chan error, 1),
+		closeNotifier: make(chan struct{}),
+	}
+}
+
+const logWatcherBufferSize = 4096
+
+// Close notifies the underlying log reader to stop.
+func (w *LogWatcher) Close() {
+	w.closeOnce.Do(func() {
+		close(w.closeNotifier)
+	})
+}
+
+// WatchClose returns a channel that's closed once the watcher has been
+// closed, so readers can stop producing further messages.
+func (w *LogWatcher) WatchClose() <-chan struct{} {
+	return w.closeNotifier
+}
+
+// Context provides enough information for a logging driver to do its job.
+type Context struct {
+	Config              map[string]string
+	ContainerID         string
+	ContainerName       string
+	ContainerEntrypoint string
+	ContainerArgs       []string
+	ContainerImageID    string
+	ContainerImageName  string
+	ContainerCreated    time.Time
+	ContainerEnv        []string
+	ContainerLabels     map[string]string
+	LogPath             string
+	DaemonName          string
+}
+
+// ExtraAttributes returns the user-defined extra attributes (labels, env
+// vars) that should be attached to each log message, with keys run through
+// keyMod (e.g. strings.ToTitle) if it's non-nil.
+func (ctx *Context) ExtraAttributes(keyMod func(string) string) map[string]string {
+	extra := make(map[string]string)
+	for k, v := range ctx.ContainerLabels {
+		if keyMod != nil {
+			k = keyMod(k)
+		}
+		extra[k] = v
+	}
+	return extra
+}
@@ -0,0 +1,50 @@
+// Package loggerutils provides helper functions shared by logging drivers.
+package loggerutils
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/docker/docker/daemon/logger"
+)
+
+// defaultTemplate is used when a container doesn't set the "tag" log opt: it
+// mirrors the short container ID docker has always defaulted to.
+const defaultTemplate = "{{.ID}}"
+
+// tagData is the data made available to a "tag" log-opt template.
+type tagData struct {
+	ID   string
+	Name string
+}
+
+// ParseLogTag parses the "tag" log opt from ctx.Config, falling back to def
+// if it's unset, and renders it against the container's ID and name.
+func ParseLogTag(ctx logger.Context, def string) (string, error) {
+	tmplStr := ctx.Config["tag"]
+	if tmplStr == "" {
+		tmplStr = def
+	}
+	if tmplStr == "" {
+		tmplStr = defaultTemplate
+	}
+
+	tmpl, err := template.New("log-tag").Parse(tmplStr)
+	if err != nil {
+		return "", err
+	}
+
+	name := ctx.ContainerName
+	if len(name) > 0 && name[0] == '/' {
+		name = name[1:]
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, &tagData{
+		ID:   ctx.ContainerID[:12],
+		Name: name,
+	}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,15 @@
+// Package ratelimit provides log-message rate limiting policies shared by
+// logging drivers that want to protect a noisy container from overwhelming
+// their backend.
+package ratelimit
+
+// Limiter decides whether a log message should be let through right now.
+//
+// Check reports whether the message is allowed, and the number of messages
+// that were suppressed since the limiter last reported a suppression count.
+// Implementations own their own notion of "since" (e.g. "in the previous
+// window" or "since tokens last ran out"); callers should treat suppressed
+// as a delta to add to their own running total.
+type Limiter interface {
+	Check() (allowed bool, suppressed int)
+}
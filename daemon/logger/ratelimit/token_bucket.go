@@ -0,0 +1,54 @@
+package ratelimit
+
+import "time"
+
+// TokenBucket is a token-bucket limiter: it accumulates tokens at Rate
+// tokens/sec up to Capacity, and allows a message whenever at least one
+// token is available. Unlike Window, it smooths bursts instead of
+// cliff-dropping them: a container that briefly exceeds the rate only loses
+// the overage, not an entire interval's worth of messages.
+type TokenBucket struct {
+	// Tokens added per second.
+	Rate float64
+	// Maximum number of tokens that can accumulate.
+	Capacity float64
+
+	// Fractional number of tokens currently available.
+	tokens float64
+	// Number of messages suppressed since tokens last ran out.
+	suppressed int
+	// Last time tokens was updated.
+	last time.Time
+}
+
+// NewTokenBucket returns a token-bucket Limiter that refills at rate
+// tokens/sec up to capacity tokens.
+func NewTokenBucket(rate, capacity float64) *TokenBucket {
+	return &TokenBucket{Rate: rate, Capacity: capacity}
+}
+
+// Check returns a boolean saying whether or not a message should be allowed
+// now, and the number of messages that were suppressed before this message.
+func (b *TokenBucket) Check() (bool, int) {
+	now := time.Now()
+
+	if b.last.IsZero() {
+		b.tokens = b.Capacity
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * b.Rate
+		if b.tokens > b.Capacity {
+			b.tokens = b.Capacity
+		}
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		suppressed := b.suppressed
+		b.suppressed = 0
+		return true, suppressed
+	}
+
+	b.suppressed++
+	return false, 0
+}
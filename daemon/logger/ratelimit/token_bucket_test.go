@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketAllowsUpToCapacity(t *testing.T) {
+	b := NewTokenBucket(1, 3)
+	for i := 0; i < 3; i++ {
+		allowed, suppressed := b.Check()
+		if !allowed {
+			t.Fatalf("Check() #%d = false, want true (within initial capacity)", i)
+		}
+		if suppressed != 0 {
+			t.Fatalf("Check() #%d suppressed = %d, want 0", i, suppressed)
+		}
+	}
+	if allowed, _ := b.Check(); allowed {
+		t.Fatalf("Check() after capacity exhausted = true, want false")
+	}
+}
+
+func TestTokenBucketRefillReportsSuppressedDelta(t *testing.T) {
+	b := NewTokenBucket(10, 1)
+	if allowed, _ := b.Check(); !allowed {
+		t.Fatalf("first Check() = false, want true")
+	}
+	for i := 0; i < 3; i++ {
+		if allowed, _ := b.Check(); allowed {
+			t.Fatalf("Check() #%d while bucket empty = true, want false", i)
+		}
+	}
+
+	// Rewind `last` rather than sleeping, so the next Check() sees enough
+	// elapsed time to refill without making the test slow or flaky.
+	b.last = b.last.Add(-time.Second)
+
+	allowed, suppressed := b.Check()
+	if !allowed {
+		t.Fatalf("Check() after refill = false, want true")
+	}
+	if suppressed != 3 {
+		t.Fatalf("Check() after refill suppressed = %d, want 3", suppressed)
+	}
+}
+
+func TestTokenBucketRefillCapsAtCapacity(t *testing.T) {
+	b := NewTokenBucket(100, 2)
+	if allowed, _ := b.Check(); !allowed {
+		t.Fatalf("first Check() = false, want true")
+	}
+
+	b.last = b.last.Add(-time.Hour)
+	if allowed, _ := b.Check(); !allowed {
+		t.Fatalf("Check() after long idle = false, want true")
+	}
+	if b.tokens > b.Capacity {
+		t.Fatalf("tokens = %v, want <= Capacity (%v)", b.tokens, b.Capacity)
+	}
+}
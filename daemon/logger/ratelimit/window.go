@@ -1,14 +1,14 @@
-package journald
+package ratelimit
 
 import "time"
 
-// rateLimit allows us to rate limit logs coming from a container before they
-// are sent to journald rather than after. While journald does its own rate
-// limiting, it has a single rate limiter for the entire docker service, so a
-// spammy container would cause us to lose logs from a less spammy container.
-// The implementation of this type is inspired by journald's
-// journal_rate_limit_test.
-type rateLimit struct {
+// Window is a fixed-window burst limiter: it allows Burst messages per
+// Interval and cliff-drops the rest, reporting how many were dropped once
+// the next interval begins.
+//
+// This is the original galaxy-specific rate limiter that used to live
+// directly in the journald driver.
+type Window struct {
 	// Number of messages to allow in each interval.
 	Burst int
 	// Length of interval.
@@ -22,9 +22,15 @@ type rateLimit struct {
 	suppressed int
 }
 
+// NewWindow returns a fixed-window Limiter allowing burst messages per
+// interval.
+func NewWindow(burst int, interval time.Duration) *Window {
+	return &Window{Burst: burst, Interval: interval}
+}
+
 // Check returns a boolean saying whether or not a message should be allowed
 // now, and the number of messages that were suppressed before this message.
-func (r *rateLimit) Check() (bool, int) {
+func (r *Window) Check() (bool, int) {
 	now := time.Now()
 
 	// Is this the first time? Start the interval.
@@ -55,8 +61,3 @@ func (r *rateLimit) Check() (bool, int) {
 	r.suppressed++
 	return false, 0
 }
-
-// Returns the number of currently suppressed messages.
-func (r *rateLimit) Suppressed() int {
-	return r.suppressed
-}
@@ -57,6 +57,18 @@ func (daemon *Daemon) StateChanged(id string, e libcontainerd.StateInfo) error {
 			logrus.Errorf("Failed to send 'stop' event to logging driver: %v", err)
 		}
 
+		// If the driver supports it, send a sentinel marking the end of this
+		// container's logs. Readers following the logs treat observing this
+		// marker as authoritative: everything is in the log store by now, so
+		// it's safe to stop waiting for more.
+		if d, ok := logDriver.(interface {
+			Died() error
+		}); ok {
+			if err := d.Died(); err != nil {
+				logrus.Errorf("Failed to send end-of-logs marker to logging driver: %v", err)
+			}
+		}
+
 		c.SetStopped(platformConstructExitStatus(e))
 		attributes := map[string]string{
 			"exitCode": strconv.Itoa(int(e.ExitCode)),